@@ -12,13 +12,19 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
+	"github.com/uzqw/vexlake/internal/cluster"
 	"github.com/uzqw/vexlake/internal/core"
+	"github.com/uzqw/vexlake/internal/persist"
+	"github.com/uzqw/vexlake/internal/pubsub"
 
 	"github.com/tidwall/redcon"
 )
@@ -28,8 +34,45 @@ var (
 	port      = flag.String("port", "6379", "Port to listen on")
 	dimension = flag.Int("dim", 128, "Vector dimension")
 	version   = "dev"
+
+	clusterEnabled = flag.Bool("cluster-enabled", false, "Run as a shard in a VexLake cluster")
+	nodeID         = flag.String("node-id", "", "Unique ID for this node (required with --cluster-enabled)")
+	clusterAddr    = flag.String("cluster-addr", "", "Address this node advertises to peers (host:port, defaults to --host:--port)")
+	clusterSlots   = flag.String("cluster-slots", "", "Inclusive slot range this node owns, e.g. 0-5460")
+
+	appendDir   = flag.String("appenddir", "./data", "Directory for the WAL and snapshot files")
+	appendFsync = flag.String("appendfsync", "everysec", "WAL fsync policy: always|everysec|no")
 )
 
+// payloads holds VSET ... PAYLOAD metadata, keyed by vector ID, alongside
+// the Rust-resident HNSW index.
+var payloads = core.NewPayloadStore()
+
+// clu is nil unless --cluster-enabled is set.
+var clu *cluster.Cluster
+
+// plog is VexLake's WAL + snapshot durability layer, opened in main before
+// ListenAndServe.
+var plog *persist.Log
+
+// broker fans out index-mutation events to SUBSCRIBE/PSUBSCRIBE clients.
+var broker = newBroker()
+
+func newBroker() *pubsub.Broker {
+	b := pubsub.NewBroker()
+	b.OnSlowSubscriber = func(sub *pubsub.Subscriber) {
+		log.Printf("pubsub: subscriber %s fell behind, disconnecting", sub.ID)
+		if v, ok := subscriberConns.Load(sub); ok {
+			v.(redcon.Conn).Close()
+		}
+	}
+	return b
+}
+
+// subscriberConns maps a live pubsub.Subscriber back to the redcon.Conn it
+// was created for, so the broker's slow-subscriber handler can close it.
+var subscriberConns sync.Map
+
 func main() {
 	flag.Parse()
 
@@ -39,8 +82,56 @@ func main() {
 	}
 	defer core.Shutdown()
 
+	fsyncPolicy, err := persist.ParseFsyncPolicy(*appendFsync)
+	if err != nil {
+		log.Fatal(err)
+	}
+	plog, err = persist.Open(*appendDir, fsyncPolicy)
+	if err != nil {
+		log.Fatalf("failed to open persistence log: %v", err)
+	}
+	defer plog.Close()
+
+	// Replay runs in the background so the server can start accepting
+	// connections immediately instead of leaving clients unable to connect
+	// at all until a large dataset finishes loading; VSET, VDEL and VSEARCH
+	// (including VSETs batched by EXEC) gate on plog.Loading() and reply
+	// -LOADING in the meantime. CLEAR does not check it.
+	log.Printf("Replaying persistence log from %s...", *appendDir)
+	go func() {
+		applyPayload := func(id uint64, payload []byte) error {
+			return payloads.Set(id, payload)
+		}
+		if err := plog.Replay(core.Insert, core.Delete, applyPayload); err != nil {
+			log.Fatalf("failed to replay persistence log: %v", err)
+		}
+		log.Printf("Replay complete")
+	}()
+
 	addr := fmt.Sprintf("%s:%s", *host, *port)
 
+	if *clusterEnabled {
+		if *nodeID == "" {
+			log.Fatal("--node-id is required with --cluster-enabled")
+		}
+		advertise := *clusterAddr
+		if advertise == "" {
+			advertise = addr
+		}
+		clu = cluster.New(*nodeID, advertise)
+		if *clusterSlots != "" {
+			start, end, err := parseSlotRange(*clusterSlots)
+			if err != nil {
+				log.Fatalf("invalid --cluster-slots: %v", err)
+			}
+			if err := clu.AssignSlots(*nodeID, start, end); err != nil {
+				log.Fatalf("failed to assign slots: %v", err)
+			}
+		}
+		clu.StartGossip()
+		defer clu.Stop()
+	}
+
 	// Create server
 	server := redcon.NewServer(addr,
 		handleCommand,
@@ -64,8 +155,70 @@ func main() {
 	}
 }
 
+// connState holds per-connection state that must survive across multiple
+// handleCommand calls: MULTI/EXEC transaction queuing, the negotiated RESP
+// protocol version, and pub/sub subscription state.
+type connState struct {
+	inMulti bool
+	queued  []redcon.Command
+	proto   int // RESP protocol version negotiated via HELLO; 0 means RESP2
+
+	sub         *pubsub.Subscriber
+	subChannels map[string]bool
+	subPatterns map[string]bool
+
+	// detached is true once this connection has been handed off to its own
+	// read loop (subscriberReadLoop or trackedReadLoop) via conn.Detach();
+	// redcon no longer drives its I/O, so handleClose must not treat the
+	// "closed" callback redcon fires the instant Detach() runs as the real
+	// disconnect — that loop's own defer owns cleanup instead.
+	detached bool
+	dconn    redcon.DetachedConn
+	// writeMu serializes writes to dconn once detached, since a write loop
+	// (pushing pub/sub messages or tracking invalidations) and a read loop
+	// (replying to the client's own commands) both write to it.
+	writeMu sync.Mutex
+}
+
+// subscribed reports whether this connection has any active SUBSCRIBE or
+// PSUBSCRIBE, per Redis semantics that restrict it to pub/sub commands.
+func (st *connState) subscribed() bool {
+	return len(st.subChannels)+len(st.subPatterns) > 0
+}
+
+func getConnState(conn redcon.Conn) *connState {
+	if ctx := conn.Context(); ctx != nil {
+		return ctx.(*connState)
+	}
+	st := &connState{}
+	conn.SetContext(st)
+	return st
+}
+
+// subCommands are the only commands a connection with an active
+// subscription may issue, matching Redis's subscriber-mode restriction.
+var subCommands = map[string]bool{
+	"SUBSCRIBE": true, "UNSUBSCRIBE": true,
+	"PSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+	"PING": true, "QUIT": true,
+}
+
 func handleCommand(conn redcon.Conn, cmd redcon.Command) {
-	switch strings.ToUpper(string(cmd.Args[0])) {
+	name := strings.ToUpper(string(cmd.Args[0]))
+	st := getConnState(conn)
+
+	if st.subscribed() && !subCommands[name] {
+		conn.WriteError("ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+		return
+	}
+
+	if st.inMulti && name != "EXEC" && name != "DISCARD" && name != "MULTI" {
+		st.queued = append(st.queued, cmd)
+		conn.WriteString("QUEUED")
+		return
+	}
+
+	switch name {
 	case "PING":
 		handlePing(conn, cmd)
 	case "ECHO":
@@ -77,20 +230,169 @@ func handleCommand(conn redcon.Conn, cmd redcon.Command) {
 		handleStats(conn)
 	case "VSET":
 		handleVSet(conn, cmd)
+	case "VGET":
+		handleVGet(conn, cmd)
+	case "VDEL":
+		handleVDel(conn, cmd)
 	case "VSEARCH":
 		handleVSearch(conn, cmd)
 	case "CLEAR":
 		handleClear(conn)
+	case "BGSAVE":
+		handleBgsave(conn)
+	case "BGREWRITEAOF":
+		handleBgrewriteaof(conn)
+	case "LASTSAVE":
+		handleLastsave(conn)
+	case "CLUSTER":
+		handleCluster(conn, cmd)
+	case "MULTI":
+		handleMulti(conn)
+	case "EXEC":
+		handleExec(conn)
+	case "DISCARD":
+		handleDiscard(conn)
+	case "HELLO":
+		handleHello(conn, cmd)
+	case "CLIENT":
+		handleClient(conn, cmd)
+	case "SUBSCRIBE":
+		handleSubscribe(conn, cmd, false)
+	case "UNSUBSCRIBE":
+		handleUnsubscribe(conn, cmd, false)
+	case "PSUBSCRIBE":
+		handleSubscribe(conn, cmd, true)
+	case "PUNSUBSCRIBE":
+		handleUnsubscribe(conn, cmd, true)
 	default:
 		conn.WriteError("ERR unknown command '" + string(cmd.Args[0]) + "'")
 	}
 }
 
+func handleMulti(conn redcon.Conn) {
+	st := getConnState(conn)
+	if st.inMulti {
+		conn.WriteError("ERR MULTI calls can not be nested")
+		return
+	}
+	st.inMulti = true
+	st.queued = nil
+	conn.WriteString("OK")
+}
+
+func handleDiscard(conn redcon.Conn) {
+	st := getConnState(conn)
+	if !st.inMulti {
+		conn.WriteError("ERR DISCARD without MULTI")
+		return
+	}
+	st.inMulti = false
+	st.queued = nil
+	conn.WriteString("OK")
+}
+
+// handleExec runs every queued command and replies with their results as a
+// single array, Redis-style. Runs of consecutive queued VSETs are coalesced
+// into one core.InsertBatch CGO call instead of one Insert call per command,
+// which is the whole point of batching a bulk load behind a transaction.
+func handleExec(conn redcon.Conn) {
+	st := getConnState(conn)
+	if !st.inMulti {
+		conn.WriteError("ERR EXEC without MULTI")
+		return
+	}
+	queued := st.queued
+	st.inMulti = false
+	st.queued = nil
+
+	conn.WriteArray(len(queued))
+	for i := 0; i < len(queued); {
+		if strings.EqualFold(string(queued[i].Args[0]), "VSET") {
+			n := execVSetBatch(conn, queued[i:])
+			if n > 0 {
+				i += n
+				continue
+			}
+		}
+		handleCommand(conn, queued[i])
+		i++
+	}
+}
+
+// execVSetBatch consumes a run of consecutive, same-dimension, payload-less
+// VSET commands from cmds, inserts them in a single core.InsertBatch call,
+// and writes one "+OK" reply per command. It returns the number of commands
+// consumed, or 0 if none could be batched (e.g. the first command isn't a
+// valid VSET), leaving the caller to fall back to handleVSet for that
+// command — which is also what happens the moment a VSET carries a PAYLOAD
+// or targets a slot this node doesn't own, since handleVSet is the only
+// path that knows how to set a payload or reply MOVED/CLUSTERDOWN. While
+// the persistence log is still replaying it batches nothing, so every VSET
+// goes through handleVSet and gets its -LOADING reply.
+func execVSetBatch(conn redcon.Conn, cmds []redcon.Command) int {
+	if plog.Loading() {
+		return 0
+	}
+	var ids []uint64
+	var vecs []float32
+	dim := -1
+
+	n := 0
+	for _, c := range cmds {
+		if !strings.EqualFold(string(c.Args[0]), "VSET") || len(c.Args) != 3 {
+			break
+		}
+		id, err := strconv.ParseUint(string(c.Args[1]), 10, 64)
+		if err != nil {
+			break
+		}
+		vec, err := parseVector(string(c.Args[2]))
+		if err != nil {
+			break
+		}
+		if dim == -1 {
+			dim = len(vec)
+		} else if len(vec) != dim {
+			break
+		}
+		if clu != nil && !clu.OwnsSlot(cluster.SlotForID(id)) {
+			break
+		}
+		ids = append(ids, id)
+		vecs = append(vecs, vec...)
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+
+	err := core.InsertBatch(ids, vecs, dim)
+	for i := 0; i < n; i++ {
+		if err != nil {
+			conn.WriteError("ERR insert failed: " + err.Error())
+			continue
+		}
+		plog.AppendSet(ids[i], vecs[i*dim:(i+1)*dim])
+		publishEvent("vexlake:inserts", map[string]interface{}{"id": ids[i], "dim": dim})
+		notifyInsert(ids[i])
+		conn.WriteString("OK")
+	}
+	return n
+}
+
 func handleAccept(conn redcon.Conn) bool {
 	return true
 }
 
 func handleClose(conn redcon.Conn, err error) {
+	st := getConnState(conn)
+	if st.detached {
+		// redcon fires this callback the instant Detach() is called, not
+		// on actual disconnect, so real teardown for a subscribed or
+		// tracked connection happens in its own read loop's defer instead.
+		return
+	}
+	disableTracking(conn)
 }
 
 func handlePing(conn redcon.Conn, cmd redcon.Command) {
@@ -111,17 +413,23 @@ func handleEcho(conn redcon.Conn, cmd redcon.Command) {
 
 func handleStats(conn redcon.Conn) {
 	stats := map[string]interface{}{
-		"version": version,
-		"status":  "ok",
-		"engine":  "hnsw",
-		"health":  core.HealthCheck(),
-		"core_v":  core.Version(),
+		"version":             version,
+		"status":              "ok",
+		"engine":              "hnsw",
+		"health":              core.HealthCheck(),
+		"core_v":              core.Version(),
+		"payload_count":       payloads.Len(),
+		"payload_cardinality": payloads.Cardinality(),
 	}
 	b, _ := json.Marshal(stats)
 	conn.WriteBulk(b)
 }
 
 func handleVSet(conn redcon.Conn, cmd redcon.Command) {
+	if plog.Loading() {
+		conn.WriteError("LOADING VexLake is loading the dataset in memory")
+		return
+	}
 	if len(cmd.Args) < 3 {
 		conn.WriteError("ERR wrong number of arguments for 'vset' command")
 		return
@@ -139,15 +447,119 @@ func handleVSet(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
+	var payload json.RawMessage
+	if len(cmd.Args) > 3 {
+		if len(cmd.Args) != 5 || !strings.EqualFold(string(cmd.Args[3]), "PAYLOAD") {
+			conn.WriteError("ERR syntax error")
+			return
+		}
+		payload = json.RawMessage(cmd.Args[4])
+	}
+
+	if clu != nil {
+		slot := cluster.SlotForID(id)
+		if !clu.OwnsSlot(slot) {
+			if owner, ok := clu.NodeForSlot(slot); ok {
+				conn.WriteError(fmt.Sprintf("MOVED %d %s", slot, owner.Addr))
+				return
+			}
+			conn.WriteError(fmt.Sprintf("CLUSTERDOWN slot %d is not assigned", slot))
+			return
+		}
+	}
+
 	if err := core.Insert(id, vec); err != nil {
 		conn.WriteError("ERR insert failed: " + err.Error())
 		return
 	}
+	plog.AppendSet(id, vec)
+	publishEvent("vexlake:inserts", map[string]interface{}{"id": id, "dim": len(vec)})
+
+	if payload != nil {
+		if err := payloads.Set(id, payload); err != nil {
+			conn.WriteError("ERR invalid payload: " + err.Error())
+			return
+		}
+		plog.AppendPayload(id, payload)
+	}
+	notifyInsert(id)
 
 	conn.WriteString("OK")
 }
 
+func handleVGet(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'vget' command")
+		return
+	}
+	id, err := strconv.ParseUint(string(cmd.Args[1]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR invalid id: must be uint64")
+		return
+	}
+
+	if clu != nil {
+		slot := cluster.SlotForID(id)
+		if !clu.OwnsSlot(slot) {
+			if owner, ok := clu.NodeForSlot(slot); ok {
+				conn.WriteError(fmt.Sprintf("MOVED %d %s", slot, owner.Addr))
+				return
+			}
+			conn.WriteError(fmt.Sprintf("CLUSTERDOWN slot %d is not assigned", slot))
+			return
+		}
+	}
+
+	payload, ok := payloads.Get(id)
+	if !ok {
+		conn.WriteNull()
+		return
+	}
+	conn.WriteBulk(payload)
+}
+
+func handleVDel(conn redcon.Conn, cmd redcon.Command) {
+	if plog.Loading() {
+		conn.WriteError("LOADING VexLake is loading the dataset in memory")
+		return
+	}
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'vdel' command")
+		return
+	}
+	id, err := strconv.ParseUint(string(cmd.Args[1]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR invalid id: must be uint64")
+		return
+	}
+
+	if clu != nil {
+		slot := cluster.SlotForID(id)
+		if !clu.OwnsSlot(slot) {
+			if owner, ok := clu.NodeForSlot(slot); ok {
+				conn.WriteError(fmt.Sprintf("MOVED %d %s", slot, owner.Addr))
+				return
+			}
+			conn.WriteError(fmt.Sprintf("CLUSTERDOWN slot %d is not assigned", slot))
+			return
+		}
+	}
+
+	if err := core.Delete(id); err != nil {
+		conn.WriteError("ERR delete failed: " + err.Error())
+		return
+	}
+	plog.AppendDel(id)
+	payloads.Delete(id)
+	publishEvent("vexlake:evictions", map[string]interface{}{"id": id})
+	conn.WriteString("OK")
+}
+
 func handleVSearch(conn redcon.Conn, cmd redcon.Command) {
+	if plog.Loading() {
+		conn.WriteError("LOADING VexLake is loading the dataset in memory")
+		return
+	}
 	if len(cmd.Args) < 3 {
 		conn.WriteError("ERR wrong number of arguments for 'vsearch' command")
 		return
@@ -166,28 +578,313 @@ func handleVSearch(conn redcon.Conn, cmd redcon.Command) {
 	}
 
 	ef := 50 // default ef
-	if len(cmd.Args) > 3 {
-		ef, _ = strconv.Atoi(string(cmd.Args[3]))
+	local := false
+	filterExpr := ""
+	args := cmd.Args[3:]
+	for i := 0; i < len(args); i++ {
+		arg := string(args[i])
+		if strings.EqualFold(arg, "LOCAL") {
+			local = true
+			continue
+		}
+		if strings.EqualFold(arg, "FILTER") {
+			rest := make([]string, 0, len(args)-i-1)
+			for _, a := range args[i+1:] {
+				rest = append(rest, string(a))
+			}
+			filterExpr = strings.Join(rest, " ")
+			break
+		}
+		if n, err := strconv.Atoi(arg); err == nil {
+			ef = n
+		}
+	}
+
+	var filter core.FilterExpr
+	if filterExpr != "" {
+		f, err := core.ParseFilter(filterExpr)
+		if err != nil {
+			conn.WriteError("ERR invalid filter: " + err.Error())
+			return
+		}
+		filter = f
 	}
 
-	results, err := core.Search(query, k, ef)
+	proto := getConnState(conn).proto
+
+	// LOCAL marks an internal scatter request from a peer shard: search only
+	// this node's own index and skip fanning out again.
+	if clu == nil || local {
+		results, err := searchLocal(query, k, ef, filter)
+		if err != nil {
+			conn.WriteError("ERR search failed: " + err.Error())
+			return
+		}
+		recordQuery(conn, query, k, ef, results)
+		writeSearchResultsProto(conn, proto, results)
+		return
+	}
+
+	results, err := scatterSearch(query, k, ef, filterExpr)
 	if err != nil {
 		conn.WriteError("ERR search failed: " + err.Error())
 		return
 	}
+	recordQuery(conn, query, k, ef, results)
+	writeSearchResultsProto(conn, proto, results)
+}
+
+// searchLocal runs a VSEARCH against this node's own index, applying filter
+// (the parsed FILTER clause, if any) via core.SearchFiltered.
+func searchLocal(query []float32, k, ef int, filter core.FilterExpr) ([]core.SearchResult, error) {
+	if filter == nil {
+		return core.Search(query, k, ef)
+	}
+	return core.SearchFiltered(query, k, ef, filter, payloads)
+}
 
+func writeSearchResults(conn redcon.Conn, results []core.SearchResult) {
 	conn.WriteArray(len(results))
 	for _, res := range results {
 		conn.WriteBulkString(fmt.Sprintf("%d:%.4f", res.ID, res.Score))
 	}
 }
 
+// scatterSearch fans a VSEARCH out to every known node (including the local
+// one) except peers the gossip prober has marked failed, each queried with
+// the LOCAL marker so it doesn't re-fan-out, then
+// merges the per-node top-k into a single top-k by score. filterExpr, if
+// non-empty, is forwarded so each shard applies it against its own payloads
+// before replying.
+func scatterSearch(query []float32, k, ef int, filterExpr string) ([]core.SearchResult, error) {
+	type nodeResult struct {
+		results []core.SearchResult
+		err     error
+	}
+
+	var filter core.FilterExpr
+	if filterExpr != "" {
+		f, err := core.ParseFilter(filterExpr)
+		if err != nil {
+			return nil, err
+		}
+		filter = f
+	}
+
+	nodes := clu.Nodes()
+	resCh := make(chan nodeResult, len(nodes))
+
+	for _, n := range nodes {
+		n := n
+		if n.ID == clu.SelfID {
+			go func() {
+				results, err := searchLocal(query, k, ef, filter)
+				resCh <- nodeResult{results, err}
+			}()
+			continue
+		}
+		if n.Failed() {
+			resCh <- nodeResult{}
+			continue
+		}
+		go func() {
+			args := []string{"VSEARCH", vectorToArg(query), strconv.Itoa(k), strconv.Itoa(ef), "LOCAL"}
+			if filterExpr != "" {
+				args = append(args, "FILTER", filterExpr)
+			}
+			raw, err := clu.Pool().Do(n.Addr, args...)
+			if err != nil {
+				resCh <- nodeResult{err: err}
+				return
+			}
+			resCh <- nodeResult{results: parseScatterReply(raw)}
+		}()
+	}
+
+	merged := make([]core.SearchResult, 0, len(nodes)*k)
+	for range nodes {
+		r := <-resCh
+		if r.err == nil {
+			merged = append(merged, r.results...)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Score < merged[j].Score })
+	if len(merged) > k {
+		merged = merged[:k]
+	}
+	return merged, nil
+}
+
+func vectorToArg(vec []float32) string {
+	parts := make([]string, len(vec))
+	for i, v := range vec {
+		parts[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// parseScatterReply decodes the newline-separated "id:score" bulk strings
+// produced by writeSearchResults on the remote peer.
+func parseScatterReply(raw []byte) []core.SearchResult {
+	var out []core.SearchResult
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		id, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		score, err := strconv.ParseFloat(parts[1], 32)
+		if err != nil {
+			continue
+		}
+		out = append(out, core.SearchResult{ID: id, Score: float32(score)})
+	}
+	return out
+}
+
+func handleCluster(conn redcon.Conn, cmd redcon.Command) {
+	if clu == nil {
+		conn.WriteError("ERR this node is not running in cluster mode")
+		return
+	}
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'cluster' command")
+		return
+	}
+
+	switch strings.ToUpper(string(cmd.Args[1])) {
+	case "SLOTS":
+		handleClusterSlots(conn)
+	case "NODES":
+		handleClusterNodes(conn)
+	case "MEET":
+		handleClusterMeet(conn, cmd)
+	default:
+		conn.WriteError("ERR unknown CLUSTER subcommand '" + string(cmd.Args[1]) + "'")
+	}
+}
+
+func handleClusterSlots(conn redcon.Conn) {
+	var ranges [][2]int
+	nodes := clu.Nodes()
+	for _, n := range nodes {
+		start, end := n.SlotRange()
+		if start < 0 {
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+
+	conn.WriteArray(len(ranges))
+	for _, rng := range ranges {
+		n, _ := clu.NodeForSlot(rng[0])
+		h, p, _ := net.SplitHostPort(n.Addr)
+		portNum, _ := strconv.Atoi(p)
+
+		conn.WriteArray(3)
+		conn.WriteInt(rng[0])
+		conn.WriteInt(rng[1])
+		conn.WriteArray(2)
+		conn.WriteBulkString(h)
+		conn.WriteInt(portNum)
+	}
+}
+
+func handleClusterNodes(conn redcon.Conn) {
+	var sb strings.Builder
+	for _, n := range clu.Nodes() {
+		start, end := n.SlotRange()
+		flags := "master"
+		if n.ID == clu.SelfID {
+			flags += ",myself"
+		}
+		if n.Failed() {
+			flags += ",fail"
+		}
+		slotStr := "-"
+		if start >= 0 {
+			slotStr = fmt.Sprintf("%d-%d", start, end)
+		}
+		fmt.Fprintf(&sb, "%s %s %s %s\n", n.ID, n.Addr, flags, slotStr)
+	}
+	conn.WriteBulkString(sb.String())
+}
+
+func handleClusterMeet(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'cluster|meet' command")
+		return
+	}
+	peerHost := string(cmd.Args[2])
+	peerPort := string(cmd.Args[3])
+	clu.AddNode(net.JoinHostPort(peerHost, peerPort), net.JoinHostPort(peerHost, peerPort))
+	conn.WriteString("OK")
+}
+
+func parseSlotRange(s string) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected START-END, got %q", s)
+	}
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
 func handleClear(conn redcon.Conn) {
 	core.Shutdown()
 	core.Init(*dimension)
+	payloads.Clear()
+	if err := plog.Reset(); err != nil {
+		conn.WriteError("ERR failed to reset persistence log: " + err.Error())
+		return
+	}
+	publishEvent("vexlake:evictions", map[string]interface{}{"all": true})
 	conn.WriteString("OK")
 }
 
+func handleBgsave(conn redcon.Conn) {
+	publishEvent("vexlake:snapshots", map[string]interface{}{"event": "bgsave_start"})
+	go func() {
+		err := plog.Snapshot()
+		if err != nil {
+			log.Printf("BGSAVE failed: %v", err)
+		}
+		publishEvent("vexlake:snapshots", map[string]interface{}{"event": "bgsave_done", "ok": err == nil})
+	}()
+	conn.WriteString("Background saving started")
+}
+
+func handleBgrewriteaof(conn redcon.Conn) {
+	publishEvent("vexlake:snapshots", map[string]interface{}{"event": "bgrewriteaof_start"})
+	go func() {
+		err := plog.RewriteAOF()
+		if err != nil {
+			log.Printf("BGREWRITEAOF failed: %v", err)
+		}
+		publishEvent("vexlake:snapshots", map[string]interface{}{"event": "bgrewriteaof_done", "ok": err == nil})
+	}()
+	conn.WriteString("Background append only file rewriting started")
+}
+
+func handleLastsave(conn redcon.Conn) {
+	conn.WriteInt(int(plog.LastSave()))
+}
+
 func parseVector(s string) ([]float32, error) {
 	s = strings.Trim(s, "[] ")
 	parts := strings.Split(s, ",")
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/tidwall/redcon"
+	"github.com/uzqw/vexlake/internal/pubsub"
+)
+
+// subscriberQueueSize bounds how many undelivered messages a slow
+// subscriber can accumulate before the broker drops further ones for it and
+// disconnects it, rather than stalling the publisher.
+const subscriberQueueSize = 128
+
+// ensureSubscriber detaches conn on a connection's first SUBSCRIBE or
+// PSUBSCRIBE so the dedicated write loop below can own its socket
+// exclusively: redcon auto-flushes a live Conn after every dispatched
+// command, and a second writer racing that would corrupt the RESP stream.
+// Once detached, redcon stops driving this connection's I/O entirely, so
+// subscriberReadLoop takes over reading and dispatching the handful of
+// commands a subscriber may still send.
+func ensureSubscriber(conn redcon.Conn, st *connState) {
+	if st.sub != nil {
+		return
+	}
+	st.sub = pubsub.NewSubscriber(conn.RemoteAddr(), subscriberQueueSize)
+	st.subChannels = make(map[string]bool)
+	st.subPatterns = make(map[string]bool)
+
+	dconn, alreadyDetached := conn.(redcon.DetachedConn)
+	if !alreadyDetached {
+		dconn = conn.Detach()
+		st.detached = true
+	}
+	st.dconn = dconn
+	subscriberConns.Store(st.sub, dconn)
+
+	go subscriberWriteLoop(st)
+	if !alreadyDetached {
+		go subscriberReadLoop(st)
+	}
+}
+
+// subscriberWriteLoop is the dedicated writer goroutine for one subscribed
+// connection: it drains the subscriber's bounded outbox and pushes each
+// message to the socket, so a publisher (an index mutation handler) never
+// waits on a subscriber's write. st.writeMu serializes it against
+// subscriberReadLoop, which writes acks for the same detached connection.
+func subscriberWriteLoop(st *connState) {
+	dconn := st.dconn
+	for msg := range st.sub.Outbox() {
+		st.writeMu.Lock()
+		if msg.Pattern != "" {
+			dconn.WriteArray(4)
+			dconn.WriteBulkString("pmessage")
+			dconn.WriteBulkString(msg.Pattern)
+		} else {
+			dconn.WriteArray(3)
+			dconn.WriteBulkString("message")
+		}
+		dconn.WriteBulkString(msg.Channel)
+		dconn.WriteBulk(msg.Payload)
+		dconn.Flush()
+		st.writeMu.Unlock()
+	}
+}
+
+// subscriberReadLoop is the sole reader of a subscribed connection once it
+// has been detached: it reads commands redcon would otherwise have
+// dispatched itself, enforces the same subscriber-mode restriction
+// handleCommand does, and owns teardown (broker unsubscribe, socket close)
+// once the client disconnects.
+func subscriberReadLoop(st *connState) {
+	dconn := st.dconn
+	defer func() {
+		broker.UnsubscribeAll(st.sub)
+		subscriberConns.Delete(st.sub)
+		st.sub.Close()
+		dconn.Close()
+	}()
+
+	for {
+		cmd, err := dconn.ReadCommand()
+		if err != nil {
+			return
+		}
+		if len(cmd.Args) == 0 {
+			continue
+		}
+
+		name := strings.ToUpper(string(cmd.Args[0]))
+		st.writeMu.Lock()
+		if !subCommands[name] {
+			if st.subscribed() {
+				dconn.WriteError("ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+			} else {
+				// Every subscription has been dropped, so the connection is
+				// out of subscriber mode: run the command normally.
+				handleCommand(dconn, cmd)
+			}
+			dconn.Flush()
+			st.writeMu.Unlock()
+			continue
+		}
+		switch name {
+		case "PING":
+			handlePing(dconn, cmd)
+		case "QUIT":
+			dconn.WriteString("OK")
+			dconn.Flush()
+			st.writeMu.Unlock()
+			return
+		case "SUBSCRIBE":
+			doSubscribe(dconn, st, cmd, false)
+		case "PSUBSCRIBE":
+			doSubscribe(dconn, st, cmd, true)
+		case "UNSUBSCRIBE":
+			doUnsubscribe(dconn, st, cmd, false)
+		case "PUNSUBSCRIBE":
+			doUnsubscribe(dconn, st, cmd, true)
+		}
+		dconn.Flush()
+		st.writeMu.Unlock()
+	}
+}
+
+func handleSubscribe(conn redcon.Conn, cmd redcon.Command, pattern bool) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'subscribe' command")
+		return
+	}
+	st := getConnState(conn)
+	ensureSubscriber(conn, st)
+
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+	doSubscribe(st.dconn, st, cmd, pattern)
+	st.dconn.Flush()
+}
+
+func handleUnsubscribe(conn redcon.Conn, cmd redcon.Command, pattern bool) {
+	st := getConnState(conn)
+	if st.dconn == nil {
+		// Never subscribed, so never detached: reply directly on the live
+		// conn, same as any other ordinary command.
+		doUnsubscribe(conn, st, cmd, pattern)
+		return
+	}
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+	doUnsubscribe(st.dconn, st, cmd, pattern)
+	st.dconn.Flush()
+}
+
+// doSubscribe and doUnsubscribe hold the actual SUBSCRIBE/UNSUBSCRIBE
+// bookkeeping, shared by the initial handleSubscribe/handleUnsubscribe call
+// and by subscriberReadLoop's redispatch of later ones; callers are
+// responsible for locking st.writeMu and flushing conn around them.
+func doSubscribe(conn redcon.Conn, st *connState, cmd redcon.Command, pattern bool) {
+	kind := "subscribe"
+	if pattern {
+		kind = "psubscribe"
+	}
+	for _, arg := range cmd.Args[1:] {
+		name := string(arg)
+		if pattern {
+			broker.PSubscribe(st.sub, name)
+			st.subPatterns[name] = true
+		} else {
+			broker.Subscribe(st.sub, name)
+			st.subChannels[name] = true
+		}
+		writeSubAck(conn, kind, name, len(st.subChannels)+len(st.subPatterns))
+	}
+}
+
+func doUnsubscribe(conn redcon.Conn, st *connState, cmd redcon.Command, pattern bool) {
+	kind := "unsubscribe"
+	subs := st.subChannels
+	if pattern {
+		kind = "punsubscribe"
+		subs = st.subPatterns
+	}
+
+	var names []string
+	if len(cmd.Args) > 1 {
+		for _, a := range cmd.Args[1:] {
+			names = append(names, string(a))
+		}
+	} else {
+		for name := range subs {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		writeSubAck(conn, kind, "", len(st.subChannels)+len(st.subPatterns))
+		return
+	}
+
+	for _, name := range names {
+		if st.sub != nil {
+			if pattern {
+				broker.PUnsubscribe(st.sub, name)
+			} else {
+				broker.Unsubscribe(st.sub, name)
+			}
+		}
+		delete(subs, name)
+		writeSubAck(conn, kind, name, len(st.subChannels)+len(st.subPatterns))
+	}
+}
+
+func writeSubAck(conn redcon.Conn, kind, name string, count int) {
+	conn.WriteArray(3)
+	conn.WriteBulkString(kind)
+	if name == "" {
+		conn.WriteNull()
+	} else {
+		conn.WriteBulkString(name)
+	}
+	conn.WriteInt(count)
+}
+
+// publishEvent JSON-encodes v and publishes it on channel. Used by
+// handleVSet/handleVDel/handleClear/BGSAVE to emit the well-known
+// vexlake:inserts / vexlake:evictions / vexlake:snapshots change-feed
+// events.
+func publishEvent(channel string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	broker.Publish(channel, b)
+}
@@ -0,0 +1,322 @@
+package main
+
+// RESP3 writer shim and CLIENT TRACKING support.
+//
+// redcon only speaks RESP2, so the RESP3-specific wire types used here (map
+// "%", double "," and push ">") are written as raw bytes via conn.WriteRaw
+// rather than through redcon's typed Write* methods, which only know RESP2
+// framing.
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/redcon"
+	"github.com/uzqw/vexlake/internal/core"
+)
+
+// trackingLRUSize bounds how many recent VSEARCH queries are remembered per
+// tracked connection for invalidation purposes.
+const trackingLRUSize = 32
+
+// trackingQueueSize bounds how many undelivered invalidate pushes a slow
+// tracking client can accumulate before newer ones are dropped. Unlike a
+// slow pub/sub subscriber, the tracking client is not disconnected.
+const trackingQueueSize = 128
+
+func handleHello(conn redcon.Conn, cmd redcon.Command) {
+	st := getConnState(conn)
+	proto := st.proto
+	if proto == 0 {
+		proto = 2
+	}
+	if len(cmd.Args) > 1 {
+		p, err := strconv.Atoi(string(cmd.Args[1]))
+		if err != nil || (p != 2 && p != 3) {
+			conn.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = p
+	}
+	st.proto = proto
+
+	fields := []string{"server", "version", "proto", "id", "mode", "role", "modules"}
+	if proto >= 3 {
+		conn.WriteRaw([]byte(fmt.Sprintf("%%%d\r\n", len(fields))))
+	} else {
+		conn.WriteArray(len(fields) * 2)
+	}
+	conn.WriteBulkString("server")
+	conn.WriteBulkString("vexlake")
+	conn.WriteBulkString("version")
+	conn.WriteBulkString(version)
+	conn.WriteBulkString("proto")
+	conn.WriteInt(proto)
+	conn.WriteBulkString("id")
+	conn.WriteInt(1)
+	conn.WriteBulkString("mode")
+	conn.WriteBulkString("standalone")
+	conn.WriteBulkString("role")
+	conn.WriteBulkString("master")
+	conn.WriteBulkString("modules")
+	conn.WriteArray(0)
+}
+
+func handleClient(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'client' command")
+		return
+	}
+
+	switch strings.ToUpper(string(cmd.Args[1])) {
+	case "TRACKING":
+		if len(cmd.Args) < 3 {
+			conn.WriteError("ERR wrong number of arguments for 'client|tracking' command")
+			return
+		}
+		switch strings.ToUpper(string(cmd.Args[2])) {
+		case "ON":
+			enableTracking(conn)
+		case "OFF":
+			disableTracking(conn)
+		default:
+			conn.WriteError("ERR CLIENT TRACKING must be ON or OFF")
+			return
+		}
+		conn.WriteString("OK")
+	default:
+		conn.WriteError("ERR unknown CLIENT subcommand '" + string(cmd.Args[1]) + "'")
+	}
+}
+
+// writeDouble writes a RESP3 double on proto 3 connections, falling back to
+// the legacy "%.4f" bulk string formatting VexLake has always used on RESP2.
+func writeDouble(conn redcon.Conn, proto int, v float32) {
+	if proto >= 3 {
+		conn.WriteRaw([]byte(fmt.Sprintf(",%g\r\n", v)))
+		return
+	}
+	conn.WriteBulkString(fmt.Sprintf("%.4f", v))
+}
+
+// writeSearchResultsProto writes VSEARCH results as the legacy "id:score"
+// bulk strings on RESP2, or as an array of {"id": <uint>, "score": <double>}
+// maps on RESP3 so clients don't have to parse a packed string.
+func writeSearchResultsProto(conn redcon.Conn, proto int, results []core.SearchResult) {
+	if proto < 3 {
+		writeSearchResults(conn, results)
+		return
+	}
+	conn.WriteArray(len(results))
+	for _, res := range results {
+		conn.WriteRaw([]byte("%2\r\n"))
+		conn.WriteBulkString("id")
+		conn.WriteInt(int(res.ID))
+		conn.WriteBulkString("score")
+		writeDouble(conn, proto, res.Score)
+	}
+}
+
+// trackedQuery is one entry in a tracked connection's recent-query LRU: the
+// query it asked and the result IDs it was shown for it.
+type trackedQuery struct {
+	query []float32
+	k     int
+	ef    int
+	ids   map[uint64]bool
+}
+
+// trackedConn is one CLIENT TRACKING ON connection. Enabling tracking
+// detaches the connection (see enableTracking) so invalidate pushes can be
+// written from notifyInsert's goroutine without racing the connection's own
+// command replies; dconn and outbox/recentMu below are all nil/unused until
+// that happens.
+type trackedConn struct {
+	dconn redcon.DetachedConn
+
+	recentMu sync.Mutex
+	recent   *list.List // of *trackedQuery, front = most recently served
+
+	// outbox queues invalidate pushes for trackedWriteLoop; writeMu is the
+	// connection's connState.writeMu, so it serializes those writes against
+	// trackedReadLoop's normal command replies on the same detached
+	// connection.
+	outbox  chan uint64
+	writeMu *sync.Mutex
+}
+
+var (
+	trackingMu sync.Mutex
+	tracking   = map[*connState]*trackedConn{}
+)
+
+// enableTracking detaches conn the first time a connection issues CLIENT
+// TRACKING ON, the same pattern SUBSCRIBE uses in pubsub.go and for the same
+// reason: redcon's own event loop can't coexist with a second goroutine
+// (here, notifyInsert's pusher) writing to the connection. Once detached,
+// trackedReadLoop takes over dispatching this connection's commands via the
+// normal handleCommand switch, since redcon stops reading from it.
+func enableTracking(conn redcon.Conn) {
+	st := getConnState(conn)
+	trackingMu.Lock()
+	if _, ok := tracking[st]; ok {
+		trackingMu.Unlock()
+		return
+	}
+	trackingMu.Unlock()
+
+	dconn, alreadyDetached := conn.(redcon.DetachedConn)
+	if !alreadyDetached {
+		dconn = conn.Detach()
+		st.detached = true
+	}
+	st.dconn = dconn
+	tc := &trackedConn{dconn: dconn, recent: list.New(), outbox: make(chan uint64, trackingQueueSize), writeMu: &st.writeMu}
+
+	trackingMu.Lock()
+	tracking[st] = tc
+	trackingMu.Unlock()
+
+	go trackedWriteLoop(tc)
+	if !alreadyDetached {
+		go trackedReadLoop(st, tc)
+	}
+}
+
+func disableTracking(conn redcon.Conn) {
+	st := getConnState(conn)
+	trackingMu.Lock()
+	tc, ok := tracking[st]
+	delete(tracking, st)
+	trackingMu.Unlock()
+	if ok {
+		close(tc.outbox)
+	}
+}
+
+// trackedReadLoop is the sole reader of a tracking connection once it has
+// been detached: it reads commands redcon would otherwise have dispatched
+// itself and runs them through the normal handleCommand switch (dconn
+// satisfies redcon.Conn), so a tracked connection keeps issuing ordinary
+// VSET/VSEARCH/etc. commands exactly as before, just over its own loop.
+func trackedReadLoop(st *connState, tc *trackedConn) {
+	dconn := tc.dconn
+	defer func() {
+		// Look up whatever trackedConn is current for st, not the one this
+		// loop started with: CLIENT TRACKING OFF/ON in between would have
+		// swapped it (the connection itself stays detached the whole time,
+		// since redcon offers no way to re-attach it).
+		trackingMu.Lock()
+		if cur, ok := tracking[st]; ok {
+			delete(tracking, st)
+			close(cur.outbox)
+		}
+		trackingMu.Unlock()
+		dconn.Close()
+	}()
+
+	for {
+		cmd, err := dconn.ReadCommand()
+		if err != nil {
+			return
+		}
+		if len(cmd.Args) == 0 {
+			continue
+		}
+		st.writeMu.Lock()
+		handleCommand(dconn, cmd)
+		dconn.Flush()
+		st.writeMu.Unlock()
+	}
+}
+
+// trackedWriteLoop drains tc's bounded invalidate queue and pushes each one
+// to the client, so notifyInsert never blocks on a tracking client's
+// socket.
+func trackedWriteLoop(tc *trackedConn) {
+	for id := range tc.outbox {
+		tc.writeMu.Lock()
+		pushInvalidate(tc.dconn, id)
+		tc.writeMu.Unlock()
+	}
+}
+
+// recordQuery remembers a VSEARCH a tracked connection just received a reply
+// for, so a later VSET can tell whether it would have changed that result.
+func recordQuery(conn redcon.Conn, query []float32, k, ef int, results []core.SearchResult) {
+	st := getConnState(conn)
+	trackingMu.Lock()
+	tc, ok := tracking[st]
+	trackingMu.Unlock()
+	if !ok {
+		return
+	}
+
+	ids := make(map[uint64]bool, len(results))
+	for _, r := range results {
+		ids[r.ID] = true
+	}
+
+	tc.recentMu.Lock()
+	defer tc.recentMu.Unlock()
+	tc.recent.PushFront(&trackedQuery{query: query, k: k, ef: ef, ids: ids})
+	for tc.recent.Len() > trackingLRUSize {
+		tc.recent.Remove(tc.recent.Back())
+	}
+}
+
+// notifyInsert checks every tracked connection's recent queries against a
+// newly inserted vector and queues an `invalidate` push for any query whose
+// top-k would now include id. Queuing never blocks: a tracking client
+// falling behind just has the push dropped and stays connected.
+func notifyInsert(id uint64) {
+	trackingMu.Lock()
+	tcs := make([]*trackedConn, 0, len(tracking))
+	for _, tc := range tracking {
+		tcs = append(tcs, tc)
+	}
+	trackingMu.Unlock()
+
+	for _, tc := range tcs {
+		tc.recentMu.Lock()
+		affected := false
+		for e := tc.recent.Front(); e != nil; e = e.Next() {
+			tq := e.Value.(*trackedQuery)
+			if tq.ids[id] {
+				continue
+			}
+			results, err := core.Search(tq.query, tq.k, tq.ef)
+			if err != nil {
+				continue
+			}
+			for _, r := range results {
+				if r.ID == id {
+					tq.ids[id] = true
+					affected = true
+					break
+				}
+			}
+		}
+		tc.recentMu.Unlock()
+		if affected {
+			select {
+			case tc.outbox <- id:
+			default:
+			}
+		}
+	}
+}
+
+// pushInvalidate sends a RESP3 out-of-band push frame telling a tracking
+// client that a cached VSEARCH result may now be stale. Callers must hold
+// the connection's writeMu.
+func pushInvalidate(conn redcon.DetachedConn, id uint64) {
+	conn.WriteRaw([]byte(">2\r\n"))
+	conn.WriteBulkString("invalidate")
+	conn.WriteArray(1)
+	conn.WriteInt(int(id))
+	conn.Flush()
+}
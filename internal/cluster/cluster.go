@@ -0,0 +1,220 @@
+// Package cluster implements consistent-hash sharding across VexLake nodes,
+// modeled on Redis Cluster: a fixed 16384-slot keyspace, a local routing
+// table mapping slots to node addresses, and MOVED redirection for
+// misrouted commands. Each process owns a subset of slots; VSEARCH scatters
+// across the full node set and merges results in the receiving node.
+package cluster
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NumSlots is the size of the cluster keyspace, mirroring Redis Cluster.
+const NumSlots = 16384
+
+// Node describes a single shard in the cluster.
+type Node struct {
+	ID   string
+	Addr string
+
+	mu         sync.RWMutex
+	slotStart  int // -1 if this node owns no slots yet
+	slotEnd    int
+	failed     bool
+	missStreak int
+}
+
+// SlotRange returns the inclusive [start, end] slot range owned by the node,
+// or (-1, -1) if the node has not been assigned any slots.
+func (n *Node) SlotRange() (int, int) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.slotStart, n.slotEnd
+}
+
+// Failed reports whether the gossip prober has marked this node unreachable.
+func (n *Node) Failed() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.failed
+}
+
+// Cluster tracks the topology known to the local node: which node owns which
+// slots, and a gossip prober that keeps the routing table live.
+type Cluster struct {
+	SelfID string
+
+	mu       sync.RWMutex
+	nodes    map[string]*Node // id -> node
+	slotNode [NumSlots]string // slot -> owning node id, "" if unassigned
+
+	pool *Pool
+
+	maxMisses  int
+	probeEvery time.Duration
+	stopCh     chan struct{}
+}
+
+// New creates a Cluster with the local node pre-registered under selfID,
+// advertising selfAddr to peers via CLUSTER SLOTS/NODES.
+func New(selfID, selfAddr string) *Cluster {
+	c := &Cluster{
+		SelfID:     selfID,
+		nodes:      make(map[string]*Node),
+		pool:       NewPool(),
+		maxMisses:  3,
+		probeEvery: time.Second,
+		stopCh:     make(chan struct{}),
+	}
+	c.nodes[selfID] = &Node{ID: selfID, Addr: selfAddr, slotStart: -1, slotEnd: -1}
+	return c
+}
+
+// SlotForID returns the slot owning a given vector ID, matching the Redis
+// Cluster CRC16-mod-16384 scheme.
+func SlotForID(id uint64) int {
+	return int(crc16([]byte(fmt.Sprintf("%d", id))) % NumSlots)
+}
+
+// AddNode registers a peer node, creating it if unknown. Safe to call
+// repeatedly (e.g. from CLUSTER MEET or gossip discovery).
+func (c *Cluster) AddNode(id, addr string) *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n, ok := c.nodes[id]; ok {
+		n.Addr = addr
+		return n
+	}
+	n := &Node{ID: id, Addr: addr, slotStart: -1, slotEnd: -1}
+	c.nodes[id] = n
+	return n
+}
+
+// AssignSlots gives node id ownership of the inclusive [start, end] range.
+func (c *Cluster) AssignSlots(id string, start, end int) error {
+	if start < 0 || end >= NumSlots || start > end {
+		return fmt.Errorf("invalid slot range %d-%d", start, end)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[id]
+	if !ok {
+		return fmt.Errorf("unknown node %q", id)
+	}
+	n.mu.Lock()
+	n.slotStart, n.slotEnd = start, end
+	n.mu.Unlock()
+	for s := start; s <= end; s++ {
+		c.slotNode[s] = id
+	}
+	return nil
+}
+
+// NodeForSlot returns the node owning slot, if any.
+func (c *Cluster) NodeForSlot(slot int) (*Node, bool) {
+	c.mu.RLock()
+	id := c.slotNode[slot]
+	n, ok := c.nodes[id]
+	c.mu.RUnlock()
+	return n, ok && id != ""
+}
+
+// OwnsSlot reports whether the local node owns slot.
+func (c *Cluster) OwnsSlot(slot int) bool {
+	n, ok := c.NodeForSlot(slot)
+	return ok && n.ID == c.SelfID
+}
+
+// Self returns the local node.
+func (c *Cluster) Self() *Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nodes[c.SelfID]
+}
+
+// Nodes returns a snapshot of every known node, including the local one.
+func (c *Cluster) Nodes() []*Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]*Node, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		out = append(out, n)
+	}
+	return out
+}
+
+// Peers returns every known node other than the local one.
+func (c *Cluster) Peers() []*Node {
+	out := make([]*Node, 0)
+	for _, n := range c.Nodes() {
+		if n.ID != c.SelfID {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Pool returns the outbound connection pool used to fan out to peers.
+func (c *Cluster) Pool() *Pool {
+	return c.pool
+}
+
+// StartGossip launches the background PING prober. Every probeEvery it pings
+// each peer; after maxMisses consecutive failures the peer is marked failed
+// and excluded from scatter/gather fan-out until it responds again.
+func (c *Cluster) StartGossip() {
+	go func() {
+		ticker := time.NewTicker(c.probeEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopCh:
+				return
+			case <-ticker.C:
+				c.probeOnce()
+			}
+		}
+	}()
+}
+
+// Stop ends the gossip prober and closes pooled connections.
+func (c *Cluster) Stop() {
+	close(c.stopCh)
+	c.pool.Close()
+}
+
+func (c *Cluster) probeOnce() {
+	for _, n := range c.Peers() {
+		_, err := c.pool.Do(n.Addr, "PING")
+		n.mu.Lock()
+		if err != nil {
+			n.missStreak++
+			if n.missStreak >= c.maxMisses {
+				n.failed = true
+			}
+		} else {
+			n.missStreak = 0
+			n.failed = false
+		}
+		n.mu.Unlock()
+	}
+}
+
+// crc16 implements the CRC16/CCITT variant used by Redis Cluster for slot
+// hashing (poly 0x1021, no reflection, initial value 0).
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,186 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// peerIOTimeout bounds how long a single Do call will wait on a peer's
+// socket. Without it, a shard that accepts the TCP connection but then
+// hangs (deadlocked, overloaded, or just slow) would block Do — and
+// therefore scatterSearch/VSEARCH — forever; the gossip prober's Failed()
+// check only protects requests issued after it notices, not one already in
+// flight against a wedged connection.
+const peerIOTimeout = 5 * time.Second
+
+// Pool is a persistent outbound RESP connection pool keyed by peer address.
+// VSEARCH fan-out and the gossip prober share it so repeated scatter/gather
+// calls reuse an already-established TCP connection instead of dialing per
+// request. Each connection carries one request/reply at a time; Do holds its
+// lock until the reply has been read.
+type Pool struct {
+	mu    sync.Mutex
+	conns map[string]*peerConn
+}
+
+type peerConn struct {
+	mu sync.Mutex
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+// NewPool creates an empty connection pool.
+func NewPool() *Pool {
+	return &Pool{conns: make(map[string]*peerConn)}
+}
+
+// Close tears down every pooled connection.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, pc := range p.conns {
+		pc.nc.Close()
+		delete(p.conns, addr)
+	}
+}
+
+// Do issues a single RESP command against addr, reusing a pooled connection
+// when available, and returns the raw reply line(s) for the caller to parse.
+func (p *Pool) Do(addr string, args ...string) ([]byte, error) {
+	pc, err := p.get(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if err := pc.nc.SetDeadline(time.Now().Add(peerIOTimeout)); err != nil {
+		p.evict(addr)
+		return nil, err
+	}
+	if err := writeCommand(pc.nc, args); err != nil {
+		p.evict(addr)
+		return nil, err
+	}
+	reply, err := readReply(pc.r)
+	if err != nil {
+		// A deadline timeout surfaces here the same as any other transport
+		// error: evict the wedged connection so the next Do redials fresh
+		// rather than hanging on it again.
+		p.evict(addr)
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (p *Pool) get(addr string) (*peerConn, error) {
+	p.mu.Lock()
+	if pc, ok := p.conns[addr]; ok {
+		p.mu.Unlock()
+		return pc, nil
+	}
+	p.mu.Unlock()
+
+	nc, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	pc := &peerConn{nc: nc, r: bufio.NewReader(nc)}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing, ok := p.conns[addr]; ok {
+		nc.Close()
+		return existing, nil
+	}
+	p.conns[addr] = pc
+	return pc, nil
+}
+
+func (p *Pool) evict(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pc, ok := p.conns[addr]; ok {
+		pc.nc.Close()
+		delete(p.conns, addr)
+	}
+}
+
+func writeCommand(w net.Conn, args []string) error {
+	buf := fmt.Sprintf("*%d\r\n", len(args))
+	for _, a := range args {
+		buf += fmt.Sprintf("$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := w.Write([]byte(buf))
+	return err
+}
+
+// readReply reads a single RESP2 reply and returns it as raw bytes, one
+// element per line, stripped of type prefixes and trailing CRLF. Good enough
+// for the simple status/bulk/array replies VexLake's own command set emits.
+func readReply(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = trimCRLF(line)
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty reply")
+	}
+
+	switch line[0] {
+	case '+', '-', ':':
+		return []byte(line[1:]), nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := readFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		var out []byte
+		for i := 0; i < n; i++ {
+			elem, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, elem...)
+			out = append(out, '\n')
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply type %q", line[0])
+	}
+}
+
+func readFull(r *bufio.Reader, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func trimCRLF(s string) string {
+	for len(s) > 0 && (s[len(s)-1] == '\n' || s[len(s)-1] == '\r') {
+		s = s[:len(s)-1]
+	}
+	return s
+}
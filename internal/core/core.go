@@ -9,6 +9,8 @@ const char* vexlake_version();
 int vexlake_init(int dim);
 void vexlake_shutdown();
 int vexlake_insert(unsigned long long id, const float* vec_ptr, int len);
+int vexlake_insert_batch(const unsigned long long* ids_ptr, const float* vecs_ptr, int n, int dim);
+int vexlake_delete(unsigned long long id);
 char* vexlake_search(const float* query_ptr, int len, int k, int ef);
 void vexlake_free_string(char* ptr);
 */
@@ -19,10 +21,13 @@ import (
 	"fmt"
 )
 
-// SearchResult matches the Rust SearchResult struct
+// SearchResult matches the Rust SearchResult struct, plus a Payload field
+// that Go fills in from the payload store after search — Rust never sees
+// payloads.
 type SearchResult struct {
-	ID    uint64  `json:"id"`
-	Score float32 `json:"score"`
+	ID      uint64          `json:"id"`
+	Score   float32         `json:"score"`
+	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
 // Init initializes the Rust engine
@@ -61,6 +66,43 @@ func Insert(id uint64, vec []float32) error {
 	return nil
 }
 
+// InsertBatch adds n vectors of the same dimension in a single CGO call.
+// vecs must be the n vectors laid out contiguously (vecs[i*dim:(i+1)*dim]
+// is the vector for ids[i]). Batching amortizes the CGO boundary crossing
+// across an entire MULTI/EXEC transaction or pipelined bulk load instead of
+// paying it per VSET.
+func InsertBatch(ids []uint64, vecs []float32, dim int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if dim <= 0 {
+		return fmt.Errorf("invalid dimension: %d", dim)
+	}
+	if len(vecs) != len(ids)*dim {
+		return fmt.Errorf("vecs length %d does not match %d ids * dim %d", len(vecs), len(ids), dim)
+	}
+
+	res := C.vexlake_insert_batch(
+		(*C.ulonglong)(&ids[0]),
+		(*C.float)(&vecs[0]),
+		C.int(len(ids)),
+		C.int(dim),
+	)
+	if res != 0 {
+		return fmt.Errorf("failed to insert batch (code: %d)", res)
+	}
+	return nil
+}
+
+// Delete removes a vector from the index.
+func Delete(id uint64) error {
+	res := C.vexlake_delete(C.ulonglong(id))
+	if res != 0 {
+		return fmt.Errorf("failed to delete vector (code: %d)", res)
+	}
+	return nil
+}
+
 // Search find the nearest neighbors for a query vector
 func Search(query []float32, k, ef int) ([]SearchResult, error) {
 	if len(query) == 0 {
@@ -81,3 +123,34 @@ func Search(query []float32, k, ef int) ([]SearchResult, error) {
 
 	return results, nil
 }
+
+// searchOversample is how many candidates SearchFiltered asks the Rust
+// index for per requested result (k*searchOversample in all), to absorb
+// whatever fraction a FilterExpr rejects.
+const searchOversample = 4
+
+// SearchFiltered asks the Rust index for k*searchOversample candidates and
+// keeps only the ones whose payload satisfies filter, returning at most k
+// survivors with their payload attached. The Rust CGO surface is unchanged
+// — filtering happens entirely in Go against payloads.
+func SearchFiltered(query []float32, k, ef int, filter FilterExpr, payloads *PayloadStore) ([]SearchResult, error) {
+	candidates, err := Search(query, k*searchOversample, ef*searchOversample)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]SearchResult, 0, k)
+	for _, c := range candidates {
+		if filter != nil && !payloads.Match(c.ID, filter) {
+			continue
+		}
+		if payload, ok := payloads.Get(c.ID); ok {
+			c.Payload = payload
+		}
+		out = append(out, c)
+		if len(out) == k {
+			break
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FilterExpr is a parsed VSEARCH ... FILTER predicate, e.g.
+// `tenant == "a" AND ts > 1699000000`. It evaluates against a payload's
+// decoded top-level JSON fields.
+type FilterExpr interface {
+	Eval(fields map[string]interface{}) bool
+}
+
+type andExpr struct{ lhs, rhs FilterExpr }
+
+func (e andExpr) Eval(fields map[string]interface{}) bool {
+	return e.lhs.Eval(fields) && e.rhs.Eval(fields)
+}
+
+type orExpr struct{ lhs, rhs FilterExpr }
+
+func (e orExpr) Eval(fields map[string]interface{}) bool {
+	return e.lhs.Eval(fields) || e.rhs.Eval(fields)
+}
+
+type cmpExpr struct {
+	field string
+	op    string
+	value interface{}
+}
+
+func (e cmpExpr) Eval(fields map[string]interface{}) bool {
+	v, ok := fields[e.field]
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "==":
+		return equalValues(v, e.value)
+	case "!=":
+		return !equalValues(v, e.value)
+	case "<", "<=", ">", ">=":
+		lf, lok := toFloat(v)
+		rf, rok := toFloat(e.value)
+		if !lok || !rok {
+			return false
+		}
+		switch e.op {
+		case "<":
+			return lf < rf
+		case "<=":
+			return lf <= rf
+		case ">":
+			return lf > rf
+		default:
+			return lf >= rf
+		}
+	default:
+		return false
+	}
+}
+
+// ParseFilter parses VexLake's small FILTER predicate language: comparisons
+// (==, !=, <, <=, >, >=) over payload fields joined with AND/OR, evaluated
+// left to right with AND binding tighter than OR (no parenthesized
+// grouping — this is a filter clause, not a query language).
+func ParseFilter(expr string) (FilterExpr, error) {
+	toks, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+	p := &filterParser{toks: toks}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("unexpected token %q", p.toks[p.pos])
+	}
+	return f, nil
+}
+
+type filterParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *filterParser) parseOr() (FilterExpr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "OR") {
+		p.pos++
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = orExpr{lhs, rhs}
+	}
+	return lhs, nil
+}
+
+func (p *filterParser) parseAnd() (FilterExpr, error) {
+	lhs, err := p.parseCmp()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "AND") {
+		p.pos++
+		rhs, err := p.parseCmp()
+		if err != nil {
+			return nil, err
+		}
+		lhs = andExpr{lhs, rhs}
+	}
+	return lhs, nil
+}
+
+func (p *filterParser) parseCmp() (FilterExpr, error) {
+	if p.pos+3 > len(p.toks) {
+		return nil, fmt.Errorf("incomplete predicate near %q", strings.Join(p.toks[p.pos:], " "))
+	}
+	field := p.toks[p.pos]
+	op := p.toks[p.pos+1]
+	litTok := p.toks[p.pos+2]
+	p.pos += 3
+
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+
+	value, err := parseLiteral(litTok)
+	if err != nil {
+		return nil, err
+	}
+	return cmpExpr{field: field, op: op, value: value}, nil
+}
+
+func (p *filterParser) peek() string {
+	if p.pos >= len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos]
+}
+
+func parseLiteral(tok string) (interface{}, error) {
+	if len(tok) >= 2 && strings.HasPrefix(tok, `"`) && strings.HasSuffix(tok, `"`) {
+		return tok[1 : len(tok)-1], nil
+	}
+	if strings.EqualFold(tok, "true") || strings.EqualFold(tok, "false") {
+		return strings.EqualFold(tok, "true"), nil
+	}
+	if f, err := strconv.ParseFloat(tok, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid literal %q", tok)
+}
+
+// tokenizeFilter splits a filter expression into field/operator/literal
+// tokens, treating "..." as a single string literal token regardless of
+// whitespace inside it.
+func tokenizeFilter(s string) ([]string, error) {
+	var toks []string
+	i, n := 0, len(s)
+	const ops = "<>=!"
+
+	for i < n {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < n && s[j] != '"' {
+				j++
+			}
+			if j >= n {
+				return nil, fmt.Errorf("unterminated string literal")
+			}
+			toks = append(toks, s[i:j+1])
+			i = j + 1
+		case strings.IndexByte(ops, c) >= 0:
+			j := i + 1
+			if j < n && s[j] == '=' {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		default:
+			j := i
+			for j < n && s[j] != ' ' && s[j] != '\t' && strings.IndexByte(ops, s[j]) < 0 {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+func equalValues(a, b interface{}) bool {
+	if af, aok := toFloat(a); aok {
+		if bf, bok := toFloat(b); bok {
+			return af == bf
+		}
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
@@ -0,0 +1,96 @@
+package core
+
+import "testing"
+
+func TestParseFilterAndEval(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		fields map[string]interface{}
+		want   bool
+	}{
+		{
+			name:   "string equality",
+			expr:   `tenant == "a"`,
+			fields: map[string]interface{}{"tenant": "a"},
+			want:   true,
+		},
+		{
+			name:   "string equality mismatch",
+			expr:   `tenant == "a"`,
+			fields: map[string]interface{}{"tenant": "b"},
+			want:   false,
+		},
+		{
+			name:   "numeric comparison",
+			expr:   "ts > 1699000000",
+			fields: map[string]interface{}{"ts": float64(1700000000)},
+			want:   true,
+		},
+		{
+			name:   "numeric literal against string field falls back to string comparison",
+			expr:   `ts == 5`,
+			fields: map[string]interface{}{"ts": "5"},
+			want:   true,
+		},
+		{
+			name:   "missing field never matches",
+			expr:   `tenant == "a"`,
+			fields: map[string]interface{}{"other": "a"},
+			want:   false,
+		},
+		{
+			name:   "AND binds tighter than OR",
+			expr:   `tenant == "a" AND active == true OR tenant == "b"`,
+			fields: map[string]interface{}{"tenant": "b", "active": false},
+			want:   true,
+		},
+		{
+			name:   "AND is false when its right-hand side is false",
+			expr:   `tenant == "a" AND active == true`,
+			fields: map[string]interface{}{"tenant": "a", "active": false},
+			want:   false,
+		},
+		{
+			name:   "quoted literal may contain spaces",
+			expr:   `name == "san francisco"`,
+			fields: map[string]interface{}{"name": "san francisco"},
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) returned error: %v", tt.expr, err)
+			}
+			if got := f.Eval(tt.fields); got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty expression", ""},
+		{"whitespace only", "   "},
+		{"incomplete predicate", "tenant =="},
+		{"unknown operator", `tenant ~= "a"`},
+		{"invalid literal", "tenant == a"},
+		{"unterminated string", `tenant == "a`},
+		{"trailing garbage", `tenant == "a" BANANA`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFilter(tt.expr); err == nil {
+				t.Errorf("ParseFilter(%q) returned no error, want one", tt.expr)
+			}
+		})
+	}
+}
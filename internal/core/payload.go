@@ -0,0 +1,126 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// PayloadStore holds JSON metadata alongside vector IDs. It keeps each
+// payload's decoded top-level fields so VSEARCH ... FILTER can be evaluated
+// without re-decoding every candidate's payload on every query, plus a
+// per-field value index that Cardinality reports on; Match does not consult
+// that index. It lives entirely on the Go side; the Rust CGO surface never
+// sees payloads.
+type PayloadStore struct {
+	mu       sync.RWMutex
+	payloads map[uint64]json.RawMessage
+	decoded  map[uint64]map[string]interface{}
+	index    map[string]map[string][]uint64 // field -> fmt.Sprint(value) -> ids
+}
+
+// NewPayloadStore creates an empty payload store.
+func NewPayloadStore() *PayloadStore {
+	return &PayloadStore{
+		payloads: make(map[uint64]json.RawMessage),
+		decoded:  make(map[uint64]map[string]interface{}),
+		index:    make(map[string]map[string][]uint64),
+	}
+}
+
+// Set stores payload for id, replacing any previous value and reindexing
+// its top-level fields for equality filters.
+func (s *PayloadStore) Set(id uint64, payload json.RawMessage) error {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return fmt.Errorf("invalid payload JSON: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unindexLocked(id)
+	s.payloads[id] = payload
+	s.decoded[id] = fields
+	for field, v := range fields {
+		key := fmt.Sprintf("%v", v)
+		if s.index[field] == nil {
+			s.index[field] = make(map[string][]uint64)
+		}
+		s.index[field][key] = append(s.index[field][key], id)
+	}
+	return nil
+}
+
+// Get returns the raw JSON payload for id, if any.
+func (s *PayloadStore) Get(id uint64) (json.RawMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.payloads[id]
+	return p, ok
+}
+
+// Delete removes id's payload and its index entries.
+func (s *PayloadStore) Delete(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unindexLocked(id)
+	delete(s.payloads, id)
+	delete(s.decoded, id)
+}
+
+// Match reports whether id's payload satisfies filter. IDs with no payload
+// never match a filtered search.
+func (s *PayloadStore) Match(id uint64, filter FilterExpr) bool {
+	s.mu.RLock()
+	fields, ok := s.decoded[id]
+	s.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	return filter.Eval(fields)
+}
+
+// Cardinality reports the number of distinct values seen for each indexed
+// field, for handleStats.
+func (s *PayloadStore) Cardinality() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]int, len(s.index))
+	for field, values := range s.index {
+		out[field] = len(values)
+	}
+	return out
+}
+
+// Len returns the number of vectors carrying a payload.
+func (s *PayloadStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.payloads)
+}
+
+// Clear removes every stored payload and index entry, for CLEAR.
+func (s *PayloadStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payloads = make(map[uint64]json.RawMessage)
+	s.decoded = make(map[uint64]map[string]interface{})
+	s.index = make(map[string]map[string][]uint64)
+}
+
+func (s *PayloadStore) unindexLocked(id uint64) {
+	fields, ok := s.decoded[id]
+	if !ok {
+		return
+	}
+	for field, v := range fields {
+		key := fmt.Sprintf("%v", v)
+		ids := s.index[field][key]
+		for i, existing := range ids {
+			if existing == id {
+				s.index[field][key] = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package persist implements VexLake's durability layer: an append-only
+// write-ahead log of VSET/VDEL operations, plus binary snapshots of the
+// mirrored in-memory state taken on demand by Snapshot (BGSAVE; no snapshot
+// is taken on a timer), replayed on startup so data survives a
+// restart. The Rust HNSW index itself is never persisted directly — Replay
+// rebuilds it by re-issuing inserts through the same core.Insert/core.Delete
+// entry points the live command handlers use.
+package persist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FsyncPolicy controls how aggressively the WAL writer calls fsync, trading
+// durability for throughput exactly like Redis's appendfsync.
+type FsyncPolicy string
+
+// The three fsync policies VexLake supports, selected via --appendfsync.
+const (
+	FsyncAlways   FsyncPolicy = "always"
+	FsyncEverySec FsyncPolicy = "everysec"
+	FsyncNo       FsyncPolicy = "no"
+)
+
+// ParseFsyncPolicy validates a --appendfsync flag value.
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch FsyncPolicy(s) {
+	case FsyncAlways, FsyncEverySec, FsyncNo:
+		return FsyncPolicy(s), nil
+	default:
+		return "", fmt.Errorf("invalid appendfsync policy %q (want always|everysec|no)", s)
+	}
+}
+
+const (
+	opSet     byte = 1
+	opDel     byte = 2
+	opPayload byte = 3
+)
+
+type walOp struct {
+	kind    byte
+	id      uint64
+	vec     []float32
+	payload []byte
+	done    chan struct{} // non-nil only under FsyncAlways; closed once apply has fsynced this op
+}
+
+// Log is VexLake's durability layer. A single-writer goroutine drains
+// queued ops onto the WAL file so command handlers never block on disk I/O
+// or fsync; it also mirrors current state in memory so Snapshot/RewriteAOF
+// don't need to ask Rust for the full index.
+type Log struct {
+	walPath  string
+	snapPath string
+	policy   FsyncPolicy
+
+	walFile *os.File
+	walW    *bufio.Writer
+	walMu   sync.Mutex // guards walFile/walW
+
+	opCh chan walOp
+
+	stateMu  sync.RWMutex
+	state    map[uint64][]float32
+	payloads map[uint64][]byte // mirrors VSET ... PAYLOAD metadata
+
+	loading  int32 // atomic bool
+	lastSave int64 // atomic unix seconds
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Open creates or reopens a Log rooted at dir and starts its single-writer
+// goroutine. It does not replay existing data onto anything — call Replay
+// for that once the caller is ready to rebuild its index.
+func Open(dir string, policy FsyncPolicy) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	l := &Log{
+		walPath:  filepath.Join(dir, "vexlake.wal"),
+		snapPath: filepath.Join(dir, "vexlake.snapshot"),
+		policy:   policy,
+		opCh:     make(chan walOp, 4096),
+		state:    make(map[uint64][]float32),
+		payloads: make(map[uint64][]byte),
+		stopCh:   make(chan struct{}),
+	}
+
+	f, err := os.OpenFile(l.walPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l.walFile = f
+	l.walW = bufio.NewWriter(f)
+
+	l.wg.Add(1)
+	go l.writerLoop()
+	if policy == FsyncEverySec {
+		l.wg.Add(1)
+		go l.everysecLoop()
+	}
+
+	return l, nil
+}
+
+// Loading reports whether Replay is still rebuilding state; handlers should
+// reply -LOADING rather than serve reads or writes while this is true.
+func (l *Log) Loading() bool {
+	return atomic.LoadInt32(&l.loading) == 1
+}
+
+// LastSave returns the Unix timestamp of the most recent successful
+// snapshot, for the LASTSAVE command. Zero if none has run yet.
+func (l *Log) LastSave() int64 {
+	return atomic.LoadInt64(&l.lastSave)
+}
+
+// AppendSet enqueues a VSET for durable logging. It blocks if the queue is
+// full rather than ever touching the WAL file on the caller's goroutine —
+// that back-pressure is the point of the single-writer design. Under
+// --appendfsync=always it additionally blocks until this op's fsync has
+// completed, so a client's +OK reply means the write actually is durable.
+func (l *Log) AppendSet(id uint64, vec []float32) {
+	l.enqueue(walOp{kind: opSet, id: id, vec: vec})
+}
+
+// AppendDel enqueues a VDEL for durable logging, with the same
+// --appendfsync=always blocking behavior as AppendSet.
+func (l *Log) AppendDel(id uint64) {
+	l.enqueue(walOp{kind: opDel, id: id})
+}
+
+// AppendPayload enqueues a VSET ... PAYLOAD's JSON metadata for durable
+// logging, alongside the vector AppendSet already persisted for the same id,
+// with the same --appendfsync=always blocking behavior as AppendSet.
+func (l *Log) AppendPayload(id uint64, payload []byte) {
+	l.enqueue(walOp{kind: opPayload, id: id, payload: payload})
+}
+
+// enqueue submits op to the writer goroutine. Under --appendfsync=always it
+// attaches a done channel and blocks until apply has fsynced this specific
+// op, so "always" actually means what it does in Redis instead of just
+// queuing the op no more durably than "everysec".
+func (l *Log) enqueue(op walOp) {
+	if l.policy == FsyncAlways {
+		op.done = make(chan struct{})
+	}
+	l.opCh <- op
+	if op.done != nil {
+		<-op.done
+	}
+}
+
+func (l *Log) writerLoop() {
+	defer l.wg.Done()
+	for {
+		select {
+		case op := <-l.opCh:
+			l.apply(op)
+		case <-l.stopCh:
+			l.drain()
+			return
+		}
+	}
+}
+
+// drain flushes whatever is left in the queue on shutdown so a clean Close
+// never silently drops a queued op.
+func (l *Log) drain() {
+	for {
+		select {
+		case op := <-l.opCh:
+			l.apply(op)
+		default:
+			l.walMu.Lock()
+			l.walW.Flush()
+			l.walMu.Unlock()
+			return
+		}
+	}
+}
+
+func (l *Log) apply(op walOp) {
+	l.walMu.Lock()
+	writeRecord(l.walW, op)
+	if l.policy == FsyncAlways {
+		l.walW.Flush()
+		l.walFile.Sync()
+	}
+
+	// The mirrored state is updated before walMu is released, so a
+	// Snapshot's WAL offset never covers an op its state copy lacks.
+	l.stateMu.Lock()
+	switch op.kind {
+	case opSet:
+		l.state[op.id] = op.vec
+	case opDel:
+		delete(l.state, op.id)
+		delete(l.payloads, op.id)
+	case opPayload:
+		l.payloads[op.id] = op.payload
+	}
+	l.stateMu.Unlock()
+	l.walMu.Unlock()
+
+	if op.done != nil {
+		close(op.done)
+	}
+}
+
+func (l *Log) everysecLoop() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.walMu.Lock()
+			l.walW.Flush()
+			l.walFile.Sync()
+			l.walMu.Unlock()
+		case <-l.stopCh:
+			return
+		}
+	}
+}
+
+// Close stops the writer goroutine, flushing any queued ops first, and
+// closes the WAL file.
+func (l *Log) Close() error {
+	close(l.stopCh)
+	l.wg.Wait()
+	return l.walFile.Close()
+}
+
+// writeRecord serializes op as a one-byte kind, then id (uint64 BE), then a
+// uint16 BE length field
+// whose meaning depends on kind: for opSet/opDel it's the vector's dim
+// (0 for a VDEL tombstone), followed by that many big-endian float32s; for
+// opPayload it's the raw JSON payload's length in bytes, followed by the
+// payload bytes themselves.
+func writeRecord(w *bufio.Writer, op walOp) error {
+	var hdr [11]byte
+	hdr[0] = op.kind
+	binary.BigEndian.PutUint64(hdr[1:9], op.id)
+	if op.kind == opPayload {
+		binary.BigEndian.PutUint16(hdr[9:11], uint16(len(op.payload)))
+		if _, err := w.Write(hdr[:]); err != nil {
+			return err
+		}
+		_, err := w.Write(op.payload)
+		return err
+	}
+
+	binary.BigEndian.PutUint16(hdr[9:11], uint16(len(op.vec)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	var b [4]byte
+	for _, f := range op.vec {
+		binary.BigEndian.PutUint32(b[:], math.Float32bits(f))
+		if _, err := w.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRecord(r *bufio.Reader) (walOp, error) {
+	var hdr [11]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return walOp{}, err
+	}
+	op := walOp{kind: hdr[0], id: binary.BigEndian.Uint64(hdr[1:9])}
+	n := binary.BigEndian.Uint16(hdr[9:11])
+	if n == 0 {
+		return op, nil
+	}
+
+	if op.kind == opPayload {
+		op.payload = make([]byte, n)
+		if _, err := io.ReadFull(r, op.payload); err != nil {
+			return walOp{}, err
+		}
+		return op, nil
+	}
+
+	op.vec = make([]float32, n)
+	var b [4]byte
+	for i := range op.vec {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return walOp{}, err
+		}
+		op.vec[i] = math.Float32frombits(binary.BigEndian.Uint32(b[:]))
+	}
+	return op, nil
+}
@@ -0,0 +1,294 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// replayRecorder collects Replay callbacks into plain maps so tests can
+// assert on final state without a real index/payload store behind
+// Insert/Delete/payloads.Set.
+type replayRecorder struct {
+	state    map[uint64][]float32
+	payloads map[uint64]string
+}
+
+func newReplayRecorder() *replayRecorder {
+	return &replayRecorder{
+		state:    make(map[uint64][]float32),
+		payloads: make(map[uint64]string),
+	}
+}
+
+func (r *replayRecorder) applySet(id uint64, vec []float32) error {
+	r.state[id] = append([]float32(nil), vec...)
+	return nil
+}
+
+func (r *replayRecorder) applyDelete(id uint64) error {
+	delete(r.state, id)
+	delete(r.payloads, id)
+	return nil
+}
+
+func (r *replayRecorder) applyPayload(id uint64, payload []byte) error {
+	r.payloads[id] = string(payload)
+	return nil
+}
+
+func TestLogReplayRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	l.AppendSet(1, []float32{1, 2, 3})
+	l.AppendSet(2, []float32{4, 5, 6})
+	l.AppendDel(1)
+	l.AppendSet(3, []float32{7, 8, 9})
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	l2, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l2.Close()
+
+	rec := newReplayRecorder()
+	if err := l2.Replay(rec.applySet, rec.applyDelete, rec.applyPayload); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	want := map[uint64][]float32{
+		2: {4, 5, 6},
+		3: {7, 8, 9},
+	}
+	if !reflect.DeepEqual(rec.state, want) {
+		t.Errorf("replayed state = %v, want %v", rec.state, want)
+	}
+}
+
+func TestLogPayloadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	l.AppendSet(1, []float32{1, 2, 3})
+	l.AppendPayload(1, []byte(`{"tenant":"a"}`))
+	l.AppendSet(2, []float32{4, 5, 6})
+	l.AppendPayload(2, []byte(`{"tenant":"b"}`))
+	l.AppendDel(1) // deleting id 1 must drop its payload too
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	l2, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l2.Close()
+
+	rec := newReplayRecorder()
+	if err := l2.Replay(rec.applySet, rec.applyDelete, rec.applyPayload); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	wantState := map[uint64][]float32{2: {4, 5, 6}}
+	if !reflect.DeepEqual(rec.state, wantState) {
+		t.Errorf("replayed state = %v, want %v", rec.state, wantState)
+	}
+	wantPayloads := map[uint64]string{2: `{"tenant":"b"}`}
+	if !reflect.DeepEqual(rec.payloads, wantPayloads) {
+		t.Errorf("replayed payloads = %v, want %v", rec.payloads, wantPayloads)
+	}
+}
+
+func TestAppendSetAlwaysBlocksUntilFsync(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, FsyncAlways)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer l.Close()
+
+	l.AppendSet(1, []float32{1, 2, 3})
+
+	// Under FsyncAlways, AppendSet must not return until its record has been
+	// flushed and fsynced, so a direct read of the WAL file right after the
+	// call (no Close, no drain) must already see it.
+	data, err := os.ReadFile(filepath.Join(dir, "vexlake.wal"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(data) == 0 {
+		t.Error("WAL file is empty immediately after AppendSet with FsyncAlways, want the record already flushed")
+	}
+}
+
+func TestLogSnapshotThenReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	l.AppendSet(1, []float32{1, 2, 3})
+	l.AppendPayload(1, []byte(`{"tenant":"a"}`))
+	l.AppendSet(2, []float32{4, 5, 6})
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Reopen and replay the WAL so this Log's mirrored state (and Snapshot
+	// below) reflect what was written above, then snapshot it and append a
+	// further record that must come back from the post-snapshot WAL tail.
+	l2, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	pre := newReplayRecorder()
+	if err := l2.Replay(pre.applySet, pre.applyDelete, pre.applyPayload); err != nil {
+		t.Fatalf("Replay before snapshot: %v", err)
+	}
+	if err := l2.Snapshot(); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	l2.AppendSet(3, []float32{7, 8, 9})
+	l2.AppendPayload(3, []byte(`{"tenant":"c"}`))
+	if err := l2.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	l3, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("reopen after snapshot: %v", err)
+	}
+	defer l3.Close()
+
+	rec := newReplayRecorder()
+	if err := l3.Replay(rec.applySet, rec.applyDelete, rec.applyPayload); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	want := map[uint64][]float32{
+		1: {1, 2, 3},
+		2: {4, 5, 6},
+		3: {7, 8, 9},
+	}
+	if !reflect.DeepEqual(rec.state, want) {
+		t.Errorf("replayed state after snapshot = %v, want %v", rec.state, want)
+	}
+	wantPayloads := map[uint64]string{1: `{"tenant":"a"}`, 3: `{"tenant":"c"}`}
+	if !reflect.DeepEqual(rec.payloads, wantPayloads) {
+		t.Errorf("replayed payloads after snapshot = %v, want %v", rec.payloads, wantPayloads)
+	}
+}
+
+func TestLogRewriteAOFThenReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	l.AppendSet(1, []float32{1, 2, 3})
+	l.AppendSet(1, []float32{9, 9, 9}) // overwrite, compaction should keep only the latest
+	l.AppendPayload(1, []byte(`{"tenant":"a"}`))
+	l.AppendSet(2, []float32{4, 5, 6})
+	l.AppendDel(2)
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	l2, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	pre := newReplayRecorder()
+	if err := l2.Replay(pre.applySet, pre.applyDelete, pre.applyPayload); err != nil {
+		t.Fatalf("Replay before rewrite: %v", err)
+	}
+	if err := l2.RewriteAOF(); err != nil {
+		t.Fatalf("RewriteAOF: %v", err)
+	}
+	if err := l2.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	l3, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("reopen after rewrite: %v", err)
+	}
+	defer l3.Close()
+
+	rec := newReplayRecorder()
+	if err := l3.Replay(rec.applySet, rec.applyDelete, rec.applyPayload); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	want := map[uint64][]float32{1: {9, 9, 9}}
+	if !reflect.DeepEqual(rec.state, want) {
+		t.Errorf("replayed state after rewrite = %v, want %v", rec.state, want)
+	}
+	wantPayloads := map[uint64]string{1: `{"tenant":"a"}`}
+	if !reflect.DeepEqual(rec.payloads, wantPayloads) {
+		t.Errorf("replayed payloads after rewrite = %v, want %v", rec.payloads, wantPayloads)
+	}
+}
+
+func TestLogReset(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	l.AppendSet(1, []float32{1, 2, 3})
+	l.AppendPayload(1, []byte(`{"tenant":"a"}`))
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	l2, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	pre := newReplayRecorder()
+	if err := l2.Replay(pre.applySet, pre.applyDelete, pre.applyPayload); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if err := l2.Reset(); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if got := l2.LastSave(); got != 0 {
+		t.Errorf("LastSave() = %d right after Reset, want 0", got)
+	}
+	if err := l2.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	l3, err := Open(dir, FsyncNo)
+	if err != nil {
+		t.Fatalf("reopen after reset: %v", err)
+	}
+	defer l3.Close()
+
+	rec := newReplayRecorder()
+	if err := l3.Replay(rec.applySet, rec.applyDelete, rec.applyPayload); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(rec.state) != 0 {
+		t.Errorf("replayed state after Reset = %v, want empty", rec.state)
+	}
+	if len(rec.payloads) != 0 {
+		t.Errorf("replayed payloads after Reset = %v, want empty", rec.payloads)
+	}
+}
@@ -0,0 +1,282 @@
+package persist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot writes the current mirrored state to a temp file then atomically
+// renames it over the snapshot path, for BGSAVE. The header records the
+// WAL's size at the moment of the snapshot so Replay knows where the WAL
+// tail that must still be replayed begins.
+func (l *Log) Snapshot() error {
+	l.walMu.Lock()
+	if err := l.walW.Flush(); err != nil {
+		l.walMu.Unlock()
+		return err
+	}
+	walOffset, err := l.walFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		l.walMu.Unlock()
+		return err
+	}
+
+	// walMu is still held, so the state copied below is exactly the state
+	// the WAL describes up to walOffset.
+	l.stateMu.RLock()
+	snapshot := make(map[uint64][]float32, len(l.state))
+	for id, vec := range l.state {
+		snapshot[id] = vec
+	}
+	payloadSnap := make(map[uint64][]byte, len(l.payloads))
+	for id, p := range l.payloads {
+		payloadSnap[id] = p
+	}
+	l.stateMu.RUnlock()
+	l.walMu.Unlock()
+
+	tmp := l.snapPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshotFile(f, walOffset, snapshot, payloadSnap); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.snapPath); err != nil {
+		return err
+	}
+
+	atomic.StoreInt64(&l.lastSave, time.Now().Unix())
+	return nil
+}
+
+// writeSnapshotFile writes a header of WAL offset, vector count and payload
+// count, followed by that many opSet records and then that many opPayload
+// records.
+func writeSnapshotFile(f *os.File, walOffset int64, state map[uint64][]float32, payloads map[uint64][]byte) error {
+	w := bufio.NewWriter(f)
+
+	var hdr [16]byte
+	binary.BigEndian.PutUint64(hdr[0:8], uint64(walOffset))
+	binary.BigEndian.PutUint32(hdr[8:12], uint32(len(state)))
+	binary.BigEndian.PutUint32(hdr[12:16], uint32(len(payloads)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	for id, vec := range state {
+		if err := writeRecord(w, walOp{kind: opSet, id: id, vec: vec}); err != nil {
+			return err
+		}
+	}
+	for id, p := range payloads {
+		if err := writeRecord(w, walOp{kind: opPayload, id: id, payload: p}); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// RewriteAOF compacts the WAL down to one "set" record per currently-live
+// vector, dropping the history of overwrites and deletes that produced that
+// state. It writes to a temp file then atomically renames it over the WAL
+// path, for BGREWRITEAOF.
+func (l *Log) RewriteAOF() error {
+	l.stateMu.RLock()
+	snapshot := make(map[uint64][]float32, len(l.state))
+	for id, vec := range l.state {
+		snapshot[id] = vec
+	}
+	payloadSnap := make(map[uint64][]byte, len(l.payloads))
+	for id, p := range l.payloads {
+		payloadSnap[id] = p
+	}
+	l.stateMu.RUnlock()
+
+	tmp := l.walPath + ".rewrite"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for id, vec := range snapshot {
+		if err := writeRecord(w, walOp{kind: opSet, id: id, vec: vec}); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	for id, p := range payloadSnap {
+		if err := writeRecord(w, walOp{kind: opPayload, id: id, payload: p}); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	l.walMu.Lock()
+	defer l.walMu.Unlock()
+
+	if err := l.walFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.walPath); err != nil {
+		return err
+	}
+	// The rewritten WAL starts from byte 0, so any existing snapshot's
+	// recorded offset into the old file no longer means anything. Drop it
+	// rather than risk a future Replay seeking into the wrong generation.
+	os.Remove(l.snapPath)
+
+	nf, err := os.OpenFile(l.walPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	l.walFile = nf
+	l.walW = bufio.NewWriter(nf)
+	return nil
+}
+
+// Replay rebuilds external state by applying the newest snapshot (if any)
+// followed by the tail of the WAL written after that snapshot was taken.
+// applySet/applyDelete/applyPayload are called once per record in replay
+// order and should mirror it into the live index and payload store
+// (core.Insert/core.Delete/payloads.Set). Loading reports true for the
+// duration of the call.
+func (l *Log) Replay(applySet func(id uint64, vec []float32) error, applyDelete func(id uint64) error, applyPayload func(id uint64, payload []byte) error) error {
+	atomic.StoreInt32(&l.loading, 1)
+	defer atomic.StoreInt32(&l.loading, 0)
+
+	var walOffset int64
+	if snap, err := os.Open(l.snapPath); err == nil {
+		walOffset, err = l.replaySnapshot(snap, applySet, applyPayload)
+		snap.Close()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	l.walMu.Lock()
+	defer l.walMu.Unlock()
+
+	if _, err := l.walFile.Seek(walOffset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(l.walFile)
+	for {
+		op, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("corrupt WAL record: %w", err)
+		}
+		switch op.kind {
+		case opSet:
+			if err := applySet(op.id, op.vec); err != nil {
+				return err
+			}
+			l.state[op.id] = op.vec
+		case opDel:
+			if err := applyDelete(op.id); err != nil {
+				return err
+			}
+			delete(l.state, op.id)
+			delete(l.payloads, op.id)
+		case opPayload:
+			if err := applyPayload(op.id, op.payload); err != nil {
+				return err
+			}
+			l.payloads[op.id] = op.payload
+		}
+	}
+	_, err := l.walFile.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (l *Log) replaySnapshot(f *os.File, applySet func(id uint64, vec []float32) error, applyPayload func(id uint64, payload []byte) error) (int64, error) {
+	r := bufio.NewReader(f)
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, fmt.Errorf("corrupt snapshot header: %w", err)
+	}
+	walOffset := int64(binary.BigEndian.Uint64(hdr[0:8]))
+	n := binary.BigEndian.Uint32(hdr[8:12])
+	m := binary.BigEndian.Uint32(hdr[12:16])
+
+	for i := uint32(0); i < n; i++ {
+		op, err := readRecord(r)
+		if err != nil {
+			return 0, fmt.Errorf("corrupt snapshot record %d: %w", i, err)
+		}
+		if err := applySet(op.id, op.vec); err != nil {
+			return 0, err
+		}
+		l.state[op.id] = op.vec
+	}
+	for i := uint32(0); i < m; i++ {
+		op, err := readRecord(r)
+		if err != nil {
+			return 0, fmt.Errorf("corrupt snapshot payload record %d: %w", i, err)
+		}
+		if err := applyPayload(op.id, op.payload); err != nil {
+			return 0, err
+		}
+		l.payloads[op.id] = op.payload
+	}
+	return walOffset, nil
+}
+
+// Reset wipes all persisted state: the WAL is truncated, any snapshot is
+// removed, and the mirrored state map is cleared. Used by CLEAR so a reset
+// index doesn't come back from the dead on the next restart.
+func (l *Log) Reset() error {
+	l.walMu.Lock()
+	defer l.walMu.Unlock()
+
+	if err := l.walFile.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.walFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	l.walW = bufio.NewWriter(l.walFile)
+
+	if err := os.Remove(l.snapPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	l.stateMu.Lock()
+	l.state = make(map[uint64][]float32)
+	l.payloads = make(map[uint64][]byte)
+	l.stateMu.Unlock()
+
+	atomic.StoreInt64(&l.lastSave, 0)
+	return nil
+}
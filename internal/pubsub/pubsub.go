@@ -0,0 +1,183 @@
+// Package pubsub implements a Redis-style publish/subscribe broker keyed by
+// channel name, with glob-pattern subscriptions. Each Subscriber owns a
+// bounded outbound queue so a slow consumer can never stall Publish —
+// redcon command handlers run on the connection's own goroutine and must
+// never block waiting on another client's socket.
+package pubsub
+
+import (
+	"path"
+	"sync"
+	"sync/atomic"
+)
+
+// Message is a single published event ready for delivery to a subscriber.
+type Message struct {
+	Channel string
+	Pattern string // set only when delivered via a PSUBSCRIBE match
+	Payload []byte
+}
+
+// Subscriber is one subscribed connection's delivery queue. The caller owns
+// a goroutine that ranges over Outbox() and writes each Message to the
+// underlying connection.
+type Subscriber struct {
+	ID string
+
+	outbox    chan Message
+	closeOnce sync.Once
+	dropped   uint64
+}
+
+// NewSubscriber creates a Subscriber with a bounded outbox of the given
+// capacity.
+func NewSubscriber(id string, queueSize int) *Subscriber {
+	return &Subscriber{ID: id, outbox: make(chan Message, queueSize)}
+}
+
+// Outbox is the channel a writer goroutine should range over to deliver
+// messages to this subscriber.
+func (s *Subscriber) Outbox() <-chan Message {
+	return s.outbox
+}
+
+// Dropped returns how many messages have been dropped for this subscriber
+// because its outbox was full.
+func (s *Subscriber) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close shuts down the subscriber's outbox, ending its writer goroutine's
+// range loop. Safe to call more than once.
+func (s *Subscriber) Close() {
+	s.closeOnce.Do(func() { close(s.outbox) })
+}
+
+// Broker tracks channel and pattern subscriptions and fans Publish calls out
+// to matching subscribers without ever blocking on one of them.
+type Broker struct {
+	mu       sync.RWMutex
+	channels map[string]map[*Subscriber]bool
+	patterns map[string]map[*Subscriber]bool
+
+	// OnSlowSubscriber, if set, is called (outside the broker's lock) for
+	// every subscriber a Publish had to drop a message for. The repo's
+	// server wires this up to log and disconnect the offending connection
+	// rather than let it silently fall behind forever.
+	OnSlowSubscriber func(*Subscriber)
+}
+
+// NewBroker creates an empty Broker.
+func NewBroker() *Broker {
+	return &Broker{
+		channels: make(map[string]map[*Subscriber]bool),
+		patterns: make(map[string]map[*Subscriber]bool),
+	}
+}
+
+// Subscribe adds sub to channel's exact-match subscriber set.
+func (b *Broker) Subscribe(sub *Subscriber, channel string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.channels[channel] == nil {
+		b.channels[channel] = make(map[*Subscriber]bool)
+	}
+	b.channels[channel][sub] = true
+}
+
+// Unsubscribe removes sub from channel.
+func (b *Broker) Unsubscribe(sub *Subscriber, channel string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.channels[channel], sub)
+	if len(b.channels[channel]) == 0 {
+		delete(b.channels, channel)
+	}
+}
+
+// PSubscribe adds sub to pattern's glob-match subscriber set.
+func (b *Broker) PSubscribe(sub *Subscriber, pattern string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.patterns[pattern] == nil {
+		b.patterns[pattern] = make(map[*Subscriber]bool)
+	}
+	b.patterns[pattern][sub] = true
+}
+
+// PUnsubscribe removes sub from pattern.
+func (b *Broker) PUnsubscribe(sub *Subscriber, pattern string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.patterns[pattern], sub)
+	if len(b.patterns[pattern]) == 0 {
+		delete(b.patterns, pattern)
+	}
+}
+
+// UnsubscribeAll removes sub from every channel and pattern it's in, for use
+// on connection close.
+func (b *Broker) UnsubscribeAll(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, subs := range b.channels {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(b.channels, ch)
+		}
+	}
+	for pat, subs := range b.patterns {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(b.patterns, pat)
+		}
+	}
+}
+
+// Publish fans payload out to every exact subscriber of channel and every
+// pattern subscriber whose pattern matches it, returning how many
+// subscribers received it. Delivery never blocks: a subscriber whose outbox
+// is full has the message dropped and OnSlowSubscriber called instead.
+func (b *Broker) Publish(channel string, payload []byte) int {
+	b.mu.RLock()
+	delivered := 0
+	var slow []*Subscriber
+
+	for sub := range b.channels[channel] {
+		if trySend(sub, Message{Channel: channel, Payload: payload}) {
+			delivered++
+		} else {
+			slow = append(slow, sub)
+		}
+	}
+	for pattern, subs := range b.patterns {
+		if ok, _ := path.Match(pattern, channel); !ok {
+			continue
+		}
+		for sub := range subs {
+			if trySend(sub, Message{Channel: channel, Pattern: pattern, Payload: payload}) {
+				delivered++
+			} else {
+				slow = append(slow, sub)
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range slow {
+		atomic.AddUint64(&sub.dropped, 1)
+		if b.OnSlowSubscriber != nil {
+			b.OnSlowSubscriber(sub)
+		}
+	}
+	return delivered
+}
+
+func trySend(sub *Subscriber, msg Message) bool {
+	select {
+	case sub.outbox <- msg:
+		return true
+	default:
+		return false
+	}
+}